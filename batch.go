@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// batchWindow is how long the coalescer waits to collect concurrent
+// calls to the same batchable method before sending them to the upstream
+// node as a single JSON-RPC batch array.
+const batchWindow = 5 * time.Millisecond
+
+// batchableMethods are the methods worth coalescing: the ones the
+// scanner issues in bulk while walking a range of blocks.
+var batchableMethods = map[string]bool{
+	"eth_getBlockByNumber":      true,
+	"eth_getTransactionReceipt": true,
+}
+
+// batchCall is one caller's request queued for the next batch flush.
+type batchCall struct {
+	ctx    context.Context
+	method string
+	params []interface{}
+	out    interface{}
+	done   chan error
+}
+
+// coalescer batches concurrent calls to the same method into a single
+// JSON-RPC batch request, reducing round-trips when many calls to the
+// same batchable method land within a short window of each other.
+type coalescer struct {
+	client *Client
+
+	mu      sync.Mutex
+	pending map[string][]*batchCall
+	timers  map[string]*time.Timer
+}
+
+func newCoalescer(client *Client) *coalescer {
+	return &coalescer{
+		client:  client,
+		pending: make(map[string][]*batchCall),
+		timers:  make(map[string]*time.Timer),
+	}
+}
+
+// submit queues a call for batching and blocks until it has been sent
+// and its result decoded, or ctx is done.
+func (co *coalescer) submit(ctx context.Context, out interface{}, method string, params ...interface{}) error {
+	bc := &batchCall{ctx: ctx, method: method, params: params, out: out, done: make(chan error, 1)}
+
+	co.mu.Lock()
+	co.pending[method] = append(co.pending[method], bc)
+	if co.timers[method] == nil {
+		co.timers[method] = time.AfterFunc(batchWindow, func() { co.flush(method) })
+	}
+	co.mu.Unlock()
+
+	select {
+	case err := <-bc.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush sends every call queued for method as one batch (or, if only one
+// call arrived during the window, as a plain single call) and delivers
+// each caller its own decoded result or error.
+func (co *coalescer) flush(method string) {
+	co.mu.Lock()
+	calls := co.pending[method]
+	delete(co.pending, method)
+	delete(co.timers, method)
+	co.mu.Unlock()
+
+	if len(calls) == 0 {
+		return
+	}
+	if len(calls) == 1 {
+		bc := calls[0]
+		bc.done <- co.client.doCall(bc.ctx, bc.out, bc.method, bc.params...)
+		return
+	}
+
+	reqs := make([]rpcRequest, len(calls))
+	for i, bc := range calls {
+		params := bc.params
+		if params == nil {
+			params = []interface{}{}
+		}
+		reqs[i] = rpcRequest{JSONRPC: "2.0", Method: bc.method, Params: params, ID: i}
+	}
+
+	// Bound the batched network call by the context of whichever caller's
+	// request opened this batch window; it's the oldest deadline in the
+	// group and a reasonable one to apply to the shared round trip.
+	resps, err := co.client.callBatch(calls[0].ctx, reqs)
+	if err != nil {
+		for _, bc := range calls {
+			bc.done <- err
+		}
+		return
+	}
+
+	byID := make(map[int]rpcResponse, len(resps))
+	for _, r := range resps {
+		byID[r.ID] = r
+	}
+	for i, bc := range calls {
+		r, ok := byID[i]
+		if !ok {
+			bc.done <- fmt.Errorf("batch response missing result for request %d", i)
+			continue
+		}
+		if r.Error != nil {
+			bc.done <- r.Error
+			continue
+		}
+		if bc.out == nil {
+			bc.done <- nil
+			continue
+		}
+		bc.done <- json.Unmarshal(r.Result, bc.out)
+	}
+}
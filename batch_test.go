@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newBatchTestServer returns an httptest.Server that answers
+// eth_getBlockByNumber, both as a plain single call and as a JSON-RPC
+// batch array, with a block whose hash echoes the requested tag. It
+// also counts how many HTTP requests it receives, so a test can assert
+// on how many round trips a set of calls collapsed into.
+func newBatchTestServer(t *testing.T) (*httptest.Server, *int64) {
+	t.Helper()
+	var hits int64
+
+	handleOne := func(req rpcRequest) rpcResponse {
+		tag, _ := req.Params[0].(string)
+		block := map[string]interface{}{
+			"number":       tag,
+			"hash":         "0x" + tag[2:],
+			"parentHash":   "0x0",
+			"timestamp":    "0x0",
+			"transactions": []interface{}{},
+		}
+		raw, _ := json.Marshal(block)
+		return rpcResponse{ID: req.ID, Result: raw}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// t.Fatal/Fatalf must only be called from the test's own
+		// goroutine, not this handler goroutine, so decode failures are
+		// reported with t.Errorf (which is goroutine-safe) and answered
+		// with an HTTP error instead of aborting the test outright.
+		atomic.AddInt64(&hits, 1)
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read request body: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			var batch []rpcRequest
+			if err := json.Unmarshal(body, &batch); err != nil {
+				t.Errorf("decode batch request: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resps := make([]rpcResponse, len(batch))
+			for i, req := range batch {
+				resps[i] = handleOne(req)
+			}
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("decode request: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(handleOne(req))
+	}))
+	return srv, &hits
+}
+
+// TestCoalescerBatchesConcurrentCalls verifies that concurrent calls to a
+// batchable method within the same batchWindow are sent upstream as a
+// single JSON-RPC batch request, and that each caller gets back the
+// result matching its own request rather than another caller's.
+func TestCoalescerBatchesConcurrentCalls(t *testing.T) {
+	srv, hits := newBatchTestServer(t)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	const n = 8
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	results := make([]string, n)
+	errs := make([]error, n)
+
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			var raw map[string]interface{}
+			errs[i] = client.call(context.Background(), &raw, "eth_getBlockByNumber", fmt.Sprintf("0x%x", i), true)
+			if errs[i] == nil {
+				results[i], _ = raw["number"].(string)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("call %d: %v", i, errs[i])
+		}
+		want := fmt.Sprintf("0x%x", i)
+		if results[i] != want {
+			t.Errorf("call %d got block number %q, want %q (batch response matched to wrong caller)", i, results[i], want)
+		}
+	}
+
+	// All n goroutines start together, well inside batchWindow, so they
+	// should coalesce into a single batch; allow a little slack for
+	// scheduler jitter rather than requiring exactly one round trip.
+	if got := atomic.LoadInt64(hits); got >= n {
+		t.Errorf("server received %d HTTP requests for %d concurrent calls, want them to have coalesced into far fewer", got, n)
+	}
+}
+
+// TestCoalescerSplitsAcrossWindows verifies that calls issued in
+// separate batch windows are sent as separate requests rather than
+// merged together.
+func TestCoalescerSplitsAcrossWindows(t *testing.T) {
+	srv, hits := newBatchTestServer(t)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	var raw map[string]interface{}
+	if err := client.call(context.Background(), &raw, "eth_getBlockByNumber", "0x1", true); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if err := client.call(context.Background(), &raw, "eth_getBlockByNumber", "0x2", true); err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+
+	if got := atomic.LoadInt64(hits); got != 2 {
+		t.Errorf("server received %d HTTP requests, want 2 (sequential calls shouldn't share a batch window)", got)
+	}
+}
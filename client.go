@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// maxAttempts bounds how many endpoints a single call will try before
+// giving up, so a call against an all-down pool fails fast instead of
+// looping forever.
+const maxAttempts = 3
+
+// Client is a typed, multi-endpoint JSON-RPC client for talking to an
+// Ethereum node, modeled on go-ethereum's ethclient: every call takes a
+// context, returns a decoded Go type instead of a raw map, and surfaces
+// RPC-level failures as an *RPCError rather than panicking on a bad type
+// assertion. Given more than one RPC URL it fails over between them,
+// rate-limits each independently, and tracks per-endpoint health and
+// latency so it can prefer the fastest one that's currently up.
+type Client struct {
+	endpoints  []*endpoint
+	httpClient *http.Client
+	metrics    *Metrics
+	coalescer  *coalescer
+
+	rrCounter uint64 // atomic round-robin cursor, used once no endpoint looks healthy
+}
+
+// NewClient returns a Client that talks to the node(s) at urls, trying
+// them in latency-weighted order and failing over on error.
+func NewClient(urls ...string) *Client {
+	endpoints := make([]*endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = newEndpoint(url)
+	}
+	c := &Client{
+		endpoints:  endpoints,
+		httpClient: &http.Client{},
+		metrics:    NewMetrics(),
+	}
+	c.coalescer = newCoalescer(c)
+	return c
+}
+
+// Metrics returns the client's request metrics collector, suitable for
+// mounting as an HTTP handler (e.g. at /metrics).
+func (c *Client) Metrics() *Metrics {
+	return c.metrics
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	ID      int           `json:"id"`
+}
+
+type rpcResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// selectEndpoint returns the best candidate endpoint to try next among
+// those not already in exclude, preferring healthy, low-latency
+// endpoints. If every endpoint has already failed this call, it falls
+// back to round-robin over all of them rather than giving up, since
+// "unhealthy" is a soft, self-healing signal rather than a hard outage.
+func (c *Client) selectEndpoint(exclude map[*endpoint]bool) *endpoint {
+	var best *endpoint
+	for _, e := range c.endpoints {
+		if exclude[e] || !e.isHealthy() {
+			continue
+		}
+		if best == nil || e.latency() < best.latency() {
+			best = e
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	var candidates []*endpoint
+	for _, e := range c.endpoints {
+		if !exclude[e] {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	idx := atomic.AddUint64(&c.rrCounter, 1) % uint64(len(candidates))
+	return candidates[idx]
+}
+
+// backoff returns the exponential-backoff delay before the attempt'th
+// (0-indexed) retry of a call, capped at one second.
+func backoff(attempt int) time.Duration {
+	d := 50 * time.Millisecond * time.Duration(uint(1)<<uint(attempt))
+	if d > time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+// withRetry runs fn against a sequence of endpoints selected by
+// selectEndpoint, skipping any that are rate-limited, retrying on a
+// different endpoint with exponential backoff when fn returns an error,
+// and recording each attempt's outcome in the client's metrics under
+// label. It gives up after maxAttempts endpoints.
+func (c *Client) withRetry(label string, fn func(ep *endpoint) error) error {
+	tried := make(map[*endpoint]bool)
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		ep := c.selectEndpoint(tried)
+		if ep == nil {
+			break
+		}
+		tried[ep] = true
+
+		if !ep.limiter.Allow() {
+			lastErr = fmt.Errorf("endpoint %s: rate limited", ep.url)
+			continue
+		}
+
+		start := time.Now()
+		err := fn(ep)
+		c.metrics.Observe(ep.url, label, time.Since(start), err)
+		if err != nil {
+			ep.markUnhealthy()
+			lastErr = err
+			time.Sleep(backoff(attempt))
+			continue
+		}
+		ep.markHealthy()
+		ep.recordLatency(time.Since(start))
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no healthy endpoints available")
+	}
+	return lastErr
+}
+
+// doCall performs a single JSON-RPC request against one of the client's
+// endpoints, retrying on a different endpoint with exponential backoff
+// if the request fails outright (transport error or 5xx). It decodes the
+// result into out, or returns an *RPCError if the node responded with an
+// error object.
+func (c *Client) doCall(ctx context.Context, out interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: 1})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	var resp *rpcResponse
+	if err := c.withRetry(method, func(ep *endpoint) error {
+		r, err := c.post(ctx, ep, body)
+		if err != nil {
+			return err
+		}
+		resp = r
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("decode result: %w", err)
+	}
+	return nil
+}
+
+// post sends body to ep and decodes the JSON-RPC envelope. An HTTP 5xx
+// is treated as a retryable transport failure, same as a network error.
+func (c *Client) post(ctx context.Context, ep *endpoint, body []byte) (*rpcResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("endpoint %s: http %d", ep.url, resp.StatusCode)
+	}
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &rpcResp, nil
+}
+
+// callBatch sends reqs together as a single JSON-RPC batch request and
+// returns the raw responses, in arbitrary order, for the caller to match
+// back to requests by ID. Endpoint selection, rate limiting, and retry
+// follow the same rules as a single call.
+func (c *Client) callBatch(ctx context.Context, reqs []rpcRequest) ([]rpcResponse, error) {
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, fmt.Errorf("marshal batch request: %w", err)
+	}
+
+	var resps []rpcResponse
+	if err := c.withRetry("batch", func(ep *endpoint) error {
+		r, err := c.postBatch(ctx, ep, body)
+		if err != nil {
+			return err
+		}
+		resps = r
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return resps, nil
+}
+
+func (c *Client) postBatch(ctx context.Context, ep *endpoint, body []byte) ([]rpcResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("endpoint %s: http %d", ep.url, resp.StatusCode)
+	}
+
+	var rpcResps []rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResps); err != nil {
+		return nil, fmt.Errorf("decode batch response: %w", err)
+	}
+	return rpcResps, nil
+}
+
+// call performs method, transparently coalescing it with concurrent
+// calls to the same batchable method into a single JSON-RPC batch
+// request when possible, and decodes its result into out. It returns an
+// *RPCError if the node responded with an error object.
+func (c *Client) call(ctx context.Context, out interface{}, method string, params ...interface{}) error {
+	if batchableMethods[method] {
+		return c.coalescer.submit(ctx, out, method, params...)
+	}
+	return c.doCall(ctx, out, method, params...)
+}
+
+// BlockNumber returns the number of the most recent block.
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	var hex string
+	if err := c.call(ctx, &hex, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return hexToUint64(hex)
+}
+
+// blockByParam fetches a block via eth_getBlockByNumber/eth_getBlockByHash
+// with full transaction objects and decodes it into an EthBlock.
+func (c *Client) blockByParam(ctx context.Context, method, param string) (*EthBlock, error) {
+	var raw map[string]interface{}
+	if err := c.call(ctx, &raw, method, param, true); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("block not found")
+	}
+	return decodeBlock(raw)
+}
+
+// BlockByNumber returns the block with the given number. A nil number
+// requests the latest block.
+func (c *Client) BlockByNumber(ctx context.Context, number *big.Int) (*EthBlock, error) {
+	tag := "latest"
+	if number != nil {
+		tag = fmt.Sprintf("0x%x", number)
+	}
+	return c.blockByParam(ctx, "eth_getBlockByNumber", tag)
+}
+
+// BlockByHash returns the block with the given hash.
+func (c *Client) BlockByHash(ctx context.Context, hash string) (*EthBlock, error) {
+	return c.blockByParam(ctx, "eth_getBlockByHash", hash)
+}
+
+// TransactionByHash returns the transaction with the given hash.
+func (c *Client) TransactionByHash(ctx context.Context, hash string) (*EthTransaction, error) {
+	var raw map[string]interface{}
+	if err := c.call(ctx, &raw, "eth_getTransactionByHash", hash); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("transaction not found")
+	}
+	return decodeTransaction(raw)
+}
+
+// CallContract performs an eth_call against the latest state, invoking
+// data (already ABI-encoded) on the contract at to, and returns the raw
+// hex-encoded return value.
+func (c *Client) CallContract(ctx context.Context, to, data string) (string, error) {
+	var result string
+	callObj := map[string]interface{}{"to": to, "data": data}
+	if err := c.call(ctx, &result, "eth_call", callObj, "latest"); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// TransactionReceipt returns the receipt for the given transaction hash.
+func (c *Client) TransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	var raw struct {
+		TxHash      string `json:"transactionHash"`
+		BlockNumber string `json:"blockNumber"`
+		Status      string `json:"status"`
+		GasUsed     string `json:"gasUsed"`
+	}
+	if err := c.call(ctx, &raw, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, err
+	}
+	blockNum, err := hexToBigInt(raw.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("decode blockNumber: %w", err)
+	}
+	status, err := hexToUint64(raw.Status)
+	if err != nil {
+		return nil, fmt.Errorf("decode status: %w", err)
+	}
+	gasUsed, err := hexToUint64(raw.GasUsed)
+	if err != nil {
+		return nil, fmt.Errorf("decode gasUsed: %w", err)
+	}
+	return &Receipt{TxHash: raw.TxHash, BlockNum: blockNum, Status: status, GasUsed: gasUsed}, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// endpointRateLimit and endpointRateBurst size the per-endpoint token
+// bucket: a sustained rate plus a small burst allowance, conservative
+// enough to stay under the quota public nodes like
+// ethereum-rpc.publicnode.com enforce.
+const (
+	endpointRateLimit = 10.0 // requests/second
+	endpointRateBurst = 20.0
+)
+
+// endpoint is one RPC URL in a Client's endpoint pool, tracked for
+// health and recent latency so the client can prefer healthy,
+// low-latency endpoints and rate-limit itself per endpoint.
+type endpoint struct {
+	url     string
+	limiter *tokenBucket
+
+	healthy int32 // atomic bool: 1 = healthy, 0 = unhealthy
+
+	mu           sync.Mutex
+	avgLatencyMs float64 // exponential moving average
+}
+
+func newEndpoint(url string) *endpoint {
+	return &endpoint{
+		url:     url,
+		limiter: newTokenBucket(endpointRateBurst, endpointRateLimit),
+		healthy: 1,
+	}
+}
+
+func (e *endpoint) markHealthy() {
+	atomic.StoreInt32(&e.healthy, 1)
+}
+
+func (e *endpoint) markUnhealthy() {
+	atomic.StoreInt32(&e.healthy, 0)
+}
+
+func (e *endpoint) isHealthy() bool {
+	return atomic.LoadInt32(&e.healthy) == 1
+}
+
+// recordLatency folds a new observed latency into the endpoint's
+// exponential moving average, used to favor faster endpoints when more
+// than one is healthy and under quota.
+func (e *endpoint) recordLatency(d time.Duration) {
+	const alpha = 0.2 // weight given to the newest sample
+	ms := float64(d.Milliseconds())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.avgLatencyMs == 0 {
+		e.avgLatencyMs = ms
+		return
+	}
+	e.avgLatencyMs = alpha*ms + (1-alpha)*e.avgLatencyMs
+}
+
+func (e *endpoint) latency() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.avgLatencyMs
+}
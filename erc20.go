@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// erc20TransferTopic is keccak256("Transfer(address,address,uint256)"),
+// the topic every ERC-20 Transfer log is indexed under.
+const erc20TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// maxLogBlockRange bounds how many blocks a single eth_getLogs call asks
+// for. Public nodes (publicnode, ankr) reject "earliest"-to-"latest"
+// queries for popular tokens, rejecting either the block range or the
+// result count, so GetTokenTransfers walks fromBlock..toBlock in
+// windows of at most this many blocks instead.
+const maxLogBlockRange = 5000
+
+// Standard ERC-20 view function selectors, used to fetch token metadata
+// via eth_call.
+const (
+	erc20NameSelector     = "0x06fdde03"
+	erc20SymbolSelector   = "0x95d89b41"
+	erc20DecimalsSelector = "0x313ce567"
+)
+
+// TokenTransfer is a decoded ERC-20 Transfer event.
+type TokenTransfer struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Value        string `json:"value"`
+	TokenAddress string `json:"tokenAddress"`
+	BlockNumber  uint64 `json:"blockNumber"`
+	TxHash       string `json:"txHash"`
+}
+
+// topicToAddress extracts the 20-byte address right-aligned in a 32-byte
+// indexed log topic.
+func topicToAddress(topic string) string {
+	topic = strings.TrimPrefix(topic, "0x")
+	if len(topic) < 40 {
+		return "0x" + topic
+	}
+	return "0x" + topic[len(topic)-40:]
+}
+
+// decodeTransferLog decodes a raw log into a TokenTransfer, returning an
+// error if it isn't a well-formed Transfer(address,address,uint256)
+// event.
+func decodeTransferLog(l *EthLog) (*TokenTransfer, error) {
+	if len(l.Topics) != 3 || !strings.EqualFold(l.Topics[0], erc20TransferTopic) {
+		return nil, fmt.Errorf("log is not an ERC-20 Transfer event")
+	}
+	value, err := hexToBigInt(l.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decode value: %w", err)
+	}
+	return &TokenTransfer{
+		From:         topicToAddress(l.Topics[1]),
+		To:           topicToAddress(l.Topics[2]),
+		Value:        value.String(),
+		TokenAddress: l.Address,
+		BlockNumber:  l.BlockNumber,
+		TxHash:       l.TxHash,
+	}, nil
+}
+
+// GetTokenTransfers returns the decoded ERC-20 Transfer events for
+// tokenAddress that involve address, either as sender or recipient,
+// between fromBlock and toBlock inclusive. The range is walked in
+// maxLogBlockRange windows rather than queried in one eth_getLogs call,
+// since public nodes cap both the block range and the result count a
+// single call can cover.
+func (c *Client) GetTokenTransfers(ctx context.Context, tokenAddress, address string, fromBlock, toBlock uint64) ([]*TokenTransfer, error) {
+	if toBlock < fromBlock {
+		return nil, fmt.Errorf("toBlock %d is before fromBlock %d", toBlock, fromBlock)
+	}
+
+	want := strings.ToLower(address)
+
+	var transfers []*TokenTransfer
+	for start := fromBlock; start <= toBlock; start += maxLogBlockRange {
+		end := start + maxLogBlockRange - 1
+		if end > toBlock {
+			end = toBlock
+		}
+
+		logs, err := c.GetLogs(ctx, LogFilter{
+			FromBlock: fmt.Sprintf("0x%x", start),
+			ToBlock:   fmt.Sprintf("0x%x", end),
+			Addresses: []string{tokenAddress},
+			Topics:    []string{erc20TransferTopic},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range logs {
+			transfer, err := decodeTransferLog(l)
+			if err != nil {
+				continue
+			}
+			if strings.ToLower(transfer.From) == want || strings.ToLower(transfer.To) == want {
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+	return transfers, nil
+}
+
+// TokenInfo is a token's static metadata, as read from its name(),
+// symbol() and decimals() view functions.
+type TokenInfo struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// TokenMetadataCache resolves and caches ERC-20 token metadata so a
+// token's name/symbol/decimals are only fetched from the chain once.
+type TokenMetadataCache struct {
+	client *Client
+
+	mu    sync.Mutex
+	cache map[string]TokenInfo
+}
+
+// NewTokenMetadataCache returns a cache that resolves metadata through
+// client.
+func NewTokenMetadataCache(client *Client) *TokenMetadataCache {
+	return &TokenMetadataCache{
+		client: client,
+		cache:  make(map[string]TokenInfo),
+	}
+}
+
+// Get returns the metadata for tokenAddress, fetching and caching it on
+// first use.
+func (t *TokenMetadataCache) Get(ctx context.Context, tokenAddress string) (TokenInfo, error) {
+	key := strings.ToLower(tokenAddress)
+
+	t.mu.Lock()
+	info, ok := t.cache[key]
+	t.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	name, err := t.callString(ctx, tokenAddress, erc20NameSelector)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("name: %w", err)
+	}
+	symbol, err := t.callString(ctx, tokenAddress, erc20SymbolSelector)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("symbol: %w", err)
+	}
+	decimals, err := t.callUint8(ctx, tokenAddress, erc20DecimalsSelector)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("decimals: %w", err)
+	}
+
+	info = TokenInfo{Name: name, Symbol: symbol, Decimals: decimals}
+	t.mu.Lock()
+	t.cache[key] = info
+	t.mu.Unlock()
+	return info, nil
+}
+
+// callUint8 calls a view function returning a single uint8 (e.g.
+// decimals()) and decodes the result.
+func (t *TokenMetadataCache) callUint8(ctx context.Context, tokenAddress, selector string) (uint8, error) {
+	result, err := t.client.CallContract(ctx, tokenAddress, selector)
+	if err != nil {
+		return 0, err
+	}
+	n, err := hexToBigInt(result)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(n.Uint64()), nil
+}
+
+// callString calls a view function returning a Solidity `string` (e.g.
+// name() or symbol()) and decodes the ABI-encoded return value: a word
+// holding the offset (always 0x20 here), a word holding the byte
+// length, then the UTF-8 bytes themselves, right-padded to a multiple of
+// 32 bytes.
+func (t *TokenMetadataCache) callString(ctx context.Context, tokenAddress, selector string) (string, error) {
+	result, err := t.client.CallContract(ctx, tokenAddress, selector)
+	if err != nil {
+		return "", err
+	}
+
+	hexData := strings.TrimPrefix(result, "0x")
+	if len(hexData) < 128 {
+		return "", fmt.Errorf("short return data for string result")
+	}
+
+	lengthWord := hexData[64:128]
+	length, ok := new(big.Int).SetString(lengthWord, 16)
+	if !ok {
+		return "", fmt.Errorf("invalid string length word %q", lengthWord)
+	}
+
+	start := 128
+	end := start + int(length.Uint64())*2
+	if end > len(hexData) {
+		return "", fmt.Errorf("return data shorter than declared string length")
+	}
+
+	raw, err := hexToBytes(hexData[start:end])
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// EthLog is the typed form of an object returned by eth_getLogs.
+type EthLog struct {
+	Address     string
+	Topics      []string
+	Data        string
+	BlockNumber uint64
+	TxHash      string
+}
+
+// LogFilter describes an eth_getLogs query. FromBlock/ToBlock accept
+// either a hex block number or one of the RPC's block tags ("earliest",
+// "latest", "pending"); a nil value defaults to "latest".
+type LogFilter struct {
+	FromBlock string
+	ToBlock   string
+	Addresses []string
+	Topics    []string
+}
+
+func blockTagOrNumber(tag string, number *big.Int) string {
+	if tag != "" {
+		return tag
+	}
+	if number != nil {
+		return fmt.Sprintf("0x%x", number)
+	}
+	return "latest"
+}
+
+// GetLogs runs an eth_getLogs query and decodes the matching logs.
+func (c *Client) GetLogs(ctx context.Context, filter LogFilter) ([]*EthLog, error) {
+	params := map[string]interface{}{
+		"fromBlock": blockTagOrNumber(filter.FromBlock, nil),
+		"toBlock":   blockTagOrNumber(filter.ToBlock, nil),
+	}
+	if len(filter.Addresses) > 0 {
+		params["address"] = filter.Addresses
+	}
+	if len(filter.Topics) > 0 {
+		params["topics"] = filter.Topics
+	}
+
+	var raw []map[string]interface{}
+	if err := c.call(ctx, &raw, "eth_getLogs", params); err != nil {
+		return nil, err
+	}
+
+	logs := make([]*EthLog, 0, len(raw))
+	for _, entry := range raw {
+		blockNumHex, _ := entry["blockNumber"].(string)
+		blockNum, err := hexToUint64(blockNumHex)
+		if err != nil {
+			return nil, fmt.Errorf("decode blockNumber: %w", err)
+		}
+
+		rawTopics, _ := entry["topics"].([]interface{})
+		topics := make([]string, 0, len(rawTopics))
+		for _, t := range rawTopics {
+			if s, ok := t.(string); ok {
+				topics = append(topics, s)
+			}
+		}
+
+		address, _ := entry["address"].(string)
+		data, _ := entry["data"].(string)
+		txHash, _ := entry["transactionHash"].(string)
+
+		logs = append(logs, &EthLog{
+			Address:     address,
+			Topics:      topics,
+			Data:        data,
+			BlockNumber: blockNum,
+			TxHash:      txHash,
+		})
+	}
+	return logs, nil
+}
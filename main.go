@@ -1,271 +1,302 @@
-package main
-
-import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
-	"regexp"
-	"strconv"
-	"sync"
-)
-
-// Transaction struct to hold transaction details
-type Transaction struct {
-	From  string `json:"from"`
-	To    string `json:"to"`
-	Value string `json:"value"`
-	Hash  string `json:"hash"`
-}
-
-// EthereumClient to interact with Ethereum JSON-RPC
-type EthereumClient struct {
-	rpcURL     string
-	httpClient *http.Client
-}
-
-func NewEthereumClient(rpcURL string) *EthereumClient {
-	return &EthereumClient{
-		rpcURL:     rpcURL,
-		httpClient: &http.Client{},
-	}
-}
-
-func (ec *EthereumClient) GetCurrentBlockNumber() (int, error) {
-	payload := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"method":  "eth_blockNumber",
-		"params":  []interface{}{},
-		"id":      1,
-	}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return 0, err
-	}
-
-	resp, err := ec.httpClient.Post(ec.rpcURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		return 0, err
-	}
-
-	blockHex := result["result"].(string)
-	blockNumber, err := strconv.ParseInt(blockHex[2:], 16, 64) // Convert hex to int
-	if err != nil {
-		return 0, err
-	}
-
-	return int(blockNumber), nil
-}
-
-func (ec *EthereumClient) GetBlockByNumber(blockNumber string) (map[string]interface{}, error) {
-	payload := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"method":  "eth_getBlockByNumber",
-		"params":  []interface{}{blockNumber, true}, // `true` for full transaction objects
-		"id":      1,
-	}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := ec.httpClient.Post(ec.rpcURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		return nil, err
-	}
-
-	if result["error"] != nil {
-		return nil, fmt.Errorf("RPC error: %v", result["error"])
-	}
-	return result["result"].(map[string]interface{}), nil
-}
-
-// ParserService manages subscriptions and transactions
-type ParserService struct {
-	client         *EthereumClient
-	subscribedAddr map[string]bool
-	mu             sync.Mutex
-}
-
-func NewParserService(client *EthereumClient) *ParserService {
-	return &ParserService{
-		client:         client,
-		subscribedAddr: make(map[string]bool),
-	}
-}
-
-func (ps *ParserService) GetCurrentBlock() int {
-	blockNumber, err := ps.client.GetCurrentBlockNumber()
-	if err != nil {
-		log.Printf("Error getting current block: %v", err)
-		return 0
-	}
-	return blockNumber
-}
-
-func (ps *ParserService) Subscribe(address string) bool {
-	ps.mu.Lock()
-	defer ps.mu.Unlock()
-	if ps.subscribedAddr[address] {
-		return false
-	}
-	ps.subscribedAddr[address] = true
-	return true
-}
-
-func (ps *ParserService) GetTransactions(address string) []Transaction {
-	// Step 1: Get the current block number
-	blockNumber := ps.GetCurrentBlock()
-
-	// Step 2: Get block details by number
-	blockDetails, err := ps.client.GetBlockByNumber(fmt.Sprintf("0x%x", blockNumber))
-	if err != nil {
-		log.Printf("Error getting block details: %v", err)
-		return nil
-	}
-
-	// Log the block details to see if data is returned
-	log.Printf("Block Details: %+v", blockDetails)
-
-	// Step 3: Filter transactions related to the given address
-	var transactions []Transaction
-	for _, tx := range blockDetails["transactions"].([]interface{}) {
-		transaction := tx.(map[string]interface{})
-		from := transaction["from"].(string)
-		to := transaction["to"].(string)
-
-		// Check if the address matches the 'from' or 'to' address
-		if from == address || to == address {
-			transactions = append(transactions, Transaction{
-				From:  from,
-				To:    to,
-				Value: transaction["value"].(string),
-				Hash:  transaction["hash"].(string),
-			})
-		}
-	}
-
-	// Step 4: Return filtered transactions
-	return transactions
-}
-
-// Utility to validate Ethereum addresses
-func isValidEthereumAddress(address string) error {
-	if len(address) != 42 {
-		return fmt.Errorf("address must be 42 characters long")
-	}
-	if address[:2] != "0x" {
-		return fmt.Errorf("address must start with '0x'")
-	}
-	isHex := regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`).MatchString
-	if !isHex(address) {
-		return fmt.Errorf("address contains invalid characters")
-	}
-	return nil
-}
-
-// Handlers
-func handleGetBlock(w http.ResponseWriter, r *http.Request, ps *ParserService) {
-	currentBlock := ps.GetCurrentBlock()
-
-	blockDetails, err := ps.client.GetBlockByNumber(fmt.Sprintf("0x%x", currentBlock))
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching block details: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	transactions := blockDetails["transactions"].([]interface{})
-	response := map[string]interface{}{
-		"blockNumber":  currentBlock,
-		"transactions": transactions,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-func handleSubscribe(w http.ResponseWriter, r *http.Request, ps *ParserService) {
-	// First check if address is provided in the headers
-	address := r.Header.Get("address")
-
-	// If not found in headers, check query parameters
-	if address == "" {
-		address = r.URL.Query().Get("address")
-	}
-
-	if address == "" {
-		http.Error(w, "Address not provided", http.StatusBadRequest)
-		return
-	}
-
-	// Validate the Ethereum address
-	if err := isValidEthereumAddress(address); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid address: %s", err.Error()), http.StatusBadRequest)
-		return
-	}
-
-	// Subscribe to the address
-	success := ps.Subscribe(address)
-	if success {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Subscribed to address: %s\n", address)
-	} else {
-		http.Error(w, "Already subscribed", http.StatusBadRequest)
-	}
-}
-
-func handleGetTransactions(w http.ResponseWriter, r *http.Request, ps *ParserService) {
-	// Extract address from query parameters or headers
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		http.Error(w, "Address not provided", http.StatusBadRequest)
-		return
-	}
-
-	// Validate the Ethereum address
-	if err := isValidEthereumAddress(address); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid address: %s", err.Error()), http.StatusBadRequest)
-		return
-	}
-
-	// Get the transactions for the address
-	transactions := ps.GetTransactions(address)
-
-	// Respond with the list of transactions
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(transactions)
-}
-
-// Main function
-func main() {
-	client := NewEthereumClient("https://ethereum-rpc.publicnode.com")
-	parserService := NewParserService(client)
-
-	http.HandleFunc("/getBlock", func(w http.ResponseWriter, r *http.Request) {
-		handleGetBlock(w, r, parserService)
-	})
-	http.HandleFunc("/subscribe", func(w http.ResponseWriter, r *http.Request) {
-		handleSubscribe(w, r, parserService)
-	})
-	http.HandleFunc("/getTransactions", func(w http.ResponseWriter, r *http.Request) {
-		handleGetTransactions(w, r, parserService)
-	})
-
-	log.Println("Server is running on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
-}
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// Transaction is the shape returned by the /getTransactions endpoint:
+// a decimal-string view of an EthTransaction suitable for JSON clients.
+type Transaction struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+	Hash  string `json:"hash"`
+}
+
+func toTransaction(tx *EthTransaction) Transaction {
+	return Transaction{
+		From:  tx.From,
+		To:    tx.To,
+		Value: tx.Value.String(),
+		Hash:  tx.Hash,
+	}
+}
+
+// ParserService manages subscriptions and transactions
+type ParserService struct {
+	client  *Client
+	storage Storage
+	subs    *Subscriptions
+}
+
+func NewParserService(client *Client, storage Storage, subs *Subscriptions) *ParserService {
+	return &ParserService{
+		client:  client,
+		storage: storage,
+		subs:    subs,
+	}
+}
+
+// GetCurrentBlock returns the current chain head, as reported by the
+// upstream node. It backs the parser_getCurrentBlock RPC method.
+func (ps *ParserService) GetCurrentBlock(ctx context.Context) (uint64, error) {
+	return ps.client.BlockNumber(ctx)
+}
+
+func (ps *ParserService) Subscribe(address string) bool {
+	return ps.subs.Add(normalizeAddress(address))
+}
+
+// GetTransactions returns every transaction the background Scanner has
+// indexed for address since it was subscribed, not just whatever is in
+// the latest block.
+func (ps *ParserService) GetTransactions(ctx context.Context, address string) []Transaction {
+	indexed, err := ps.storage.TransactionsByAddress(normalizeAddress(address))
+	if err != nil {
+		log.Printf("Error reading transaction index: %v", err)
+		return nil
+	}
+
+	transactions := make([]Transaction, 0, len(indexed))
+	for _, tx := range indexed {
+		transactions = append(transactions, tx.Transaction)
+	}
+	return transactions
+}
+
+// Utility to validate Ethereum addresses
+func isValidEthereumAddress(address string) error {
+	if len(address) != 42 {
+		return fmt.Errorf("address must be 42 characters long")
+	}
+	if address[:2] != "0x" {
+		return fmt.Errorf("address must start with '0x'")
+	}
+	isHex := regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`).MatchString
+	if !isHex(address) {
+		return fmt.Errorf("address contains invalid characters")
+	}
+	return nil
+}
+
+// parseBlockParam reads a block-number query parameter, returning def
+// when it's absent.
+func parseBlockParam(r *http.Request, name string, def uint64) (uint64, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, raw)
+	}
+	return n, nil
+}
+
+// Handlers
+func handleGetBlock(w http.ResponseWriter, r *http.Request, ps *ParserService) {
+	block, err := ps.client.BlockByNumber(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching block details: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	transactions := make([]Transaction, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		transactions = append(transactions, toTransaction(tx))
+	}
+	response := map[string]interface{}{
+		"blockNumber":  block.Number.String(),
+		"transactions": transactions,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleSubscribe(w http.ResponseWriter, r *http.Request, ps *ParserService) {
+	// First check if address is provided in the headers
+	address := r.Header.Get("address")
+
+	// If not found in headers, check query parameters
+	if address == "" {
+		address = r.URL.Query().Get("address")
+	}
+
+	if address == "" {
+		http.Error(w, "Address not provided", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the Ethereum address
+	if err := isValidEthereumAddress(address); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	// Subscribe to the address
+	success := ps.Subscribe(address)
+	if success {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Subscribed to address: %s\n", address)
+	} else {
+		http.Error(w, "Already subscribed", http.StatusBadRequest)
+	}
+}
+
+func handleGetTransactions(w http.ResponseWriter, r *http.Request, ps *ParserService) {
+	// Extract address from query parameters or headers
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address not provided", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the Ethereum address
+	if err := isValidEthereumAddress(address); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	// Get the transactions for the address
+	transactions := ps.GetTransactions(r.Context(), address)
+
+	// Respond with the list of transactions
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+// defaultTokenTransferLookback bounds how far back /getTokenTransactions
+// scans when the caller doesn't supply an explicit fromBlock, so the
+// default request stays within the block-range caps public nodes
+// enforce instead of attempting a whole-chain scan.
+const defaultTokenTransferLookback = 10_000
+
+// tokenTransferView is a TokenTransfer plus its token's resolved
+// name/symbol/decimals, as returned by /getTokenTransactions.
+type tokenTransferView struct {
+	TokenTransfer
+	TokenName     string `json:"tokenName,omitempty"`
+	TokenSymbol   string `json:"tokenSymbol,omitempty"`
+	TokenDecimals uint8  `json:"tokenDecimals,omitempty"`
+}
+
+func handleGetTokenTransactions(w http.ResponseWriter, r *http.Request, client *Client, tokens *TokenMetadataCache) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address not provided", http.StatusBadRequest)
+		return
+	}
+	if err := isValidEthereumAddress(address); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Token address not provided", http.StatusBadRequest)
+		return
+	}
+	if err := isValidEthereumAddress(token); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid token address: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	toBlock, err := parseBlockParam(r, "toBlock", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if toBlock == 0 {
+		toBlock, err = client.BlockNumber(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error fetching current block: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	fromBlockDefault := uint64(0)
+	if toBlock > defaultTokenTransferLookback {
+		fromBlockDefault = toBlock - defaultTokenTransferLookback
+	}
+	fromBlock, err := parseBlockParam(r, "fromBlock", fromBlockDefault)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transfers, err := client.GetTokenTransfers(r.Context(), token, address, fromBlock, toBlock)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching token transfers: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	info, err := tokens.Get(r.Context(), token)
+	if err != nil {
+		log.Printf("Error resolving metadata for token %s: %v", token, err)
+	}
+
+	views := make([]tokenTransferView, 0, len(transfers))
+	for _, t := range transfers {
+		views = append(views, tokenTransferView{
+			TokenTransfer: *t,
+			TokenName:     info.Name,
+			TokenSymbol:   info.Symbol,
+			TokenDecimals: info.Decimals,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// Main function
+func main() {
+	client := NewClient(
+		"https://ethereum-rpc.publicnode.com",
+		"https://rpc.ankr.com/eth",
+	)
+
+	storage, err := NewFileStorage("scanner-state.json")
+	if err != nil {
+		log.Fatalf("Error opening scanner storage: %v", err)
+	}
+	subs := NewSubscriptions()
+	parserService := NewParserService(client, storage, subs)
+
+	notifier := NewNotifier(client, "wss://ethereum-rpc.publicnode.com")
+	scanner := NewScanner(client, storage, subs)
+	tokenMetadata := NewTokenMetadataCache(client)
+	rpcServer := NewRPCServer(client)
+	rpcServer.RegisterService("parser", parserService)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+	go scanner.Run(ctx)
+
+	http.HandleFunc("/getBlock", func(w http.ResponseWriter, r *http.Request) {
+		handleGetBlock(w, r, parserService)
+	})
+	http.HandleFunc("/subscribe", func(w http.ResponseWriter, r *http.Request) {
+		handleSubscribe(w, r, parserService)
+	})
+	http.HandleFunc("/getTransactions", func(w http.ResponseWriter, r *http.Request) {
+		handleGetTransactions(w, r, parserService)
+	})
+	http.HandleFunc("/getTokenTransactions", func(w http.ResponseWriter, r *http.Request) {
+		handleGetTokenTransactions(w, r, client, tokenMetadata)
+	})
+	http.HandleFunc("/ws", notifier.HandleWS)
+	http.HandleFunc("/rpc", rpcServer.ServeHTTP)
+	http.HandleFunc("/rpcws", rpcServer.HandleWS)
+	http.Handle("/metrics", client.Metrics())
+
+	log.Println("Server is running on port 8080")
+	log.Fatal(http.ListenAndServe(":8080", nil))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBucketBounds are the histogram bucket upper bounds, in seconds,
+// used for the request duration histogram (the same default ladder
+// Prometheus client libraries ship with).
+var latencyBucketBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// endpointStats tracks request counts, error counts, and a latency
+// histogram for a single RPC endpoint.
+type endpointStats struct {
+	requests         uint64
+	errors           uint64
+	requestsByMethod map[string]uint64
+	bucketCounts     []uint64 // cumulative count per latencyBucketBounds entry; +Inf is latencyCount
+	latencySum       float64
+	latencyCount     uint64
+}
+
+func newEndpointStats() *endpointStats {
+	return &endpointStats{
+		requestsByMethod: make(map[string]uint64),
+		bucketCounts:     make([]uint64, len(latencyBucketBounds)),
+	}
+}
+
+// Metrics collects Prometheus-style counters and histograms for the
+// multi-endpoint client: request counts, latency, and errors broken
+// down per endpoint.
+type Metrics struct {
+	mu    sync.Mutex
+	stats map[string]*endpointStats // keyed by endpoint URL
+}
+
+// NewMetrics returns an empty Metrics collector.
+func NewMetrics() *Metrics {
+	return &Metrics{stats: make(map[string]*endpointStats)}
+}
+
+// Observe records the outcome of one RPC call made against endpoint.
+func (m *Metrics) Observe(endpoint, method string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.stats[endpoint]
+	if !ok {
+		stats = newEndpointStats()
+		m.stats[endpoint] = stats
+	}
+
+	stats.requests++
+	stats.requestsByMethod[method]++
+	if err != nil {
+		stats.errors++
+	}
+
+	seconds := duration.Seconds()
+	stats.latencySum += seconds
+	stats.latencyCount++
+	for i, bound := range latencyBucketBounds {
+		if seconds <= bound {
+			stats.bucketCounts[i]++
+		}
+	}
+}
+
+// ServeHTTP exposes the collected metrics in Prometheus text exposition
+// format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeMetrics(w)
+}
+
+// writeMetrics renders all collected metrics in Prometheus text
+// exposition format. It isn't named WriteTo: that name is reserved for
+// io.WriterTo's (io.Writer) (int64, error) signature, which this doesn't
+// implement.
+func (m *Metrics) writeMetrics(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	endpoints := make([]string, 0, len(m.stats))
+	for endpoint := range m.stats {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintln(w, "# HELP rpc_requests_total Total RPC requests sent per endpoint and method.")
+	fmt.Fprintln(w, "# TYPE rpc_requests_total counter")
+	for _, endpoint := range endpoints {
+		methods := make([]string, 0, len(m.stats[endpoint].requestsByMethod))
+		for method := range m.stats[endpoint].requestsByMethod {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			fmt.Fprintf(w, "rpc_requests_total{endpoint=%q,method=%q} %d\n", endpoint, method, m.stats[endpoint].requestsByMethod[method])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP rpc_request_errors_total Total RPC requests that failed per endpoint.")
+	fmt.Fprintln(w, "# TYPE rpc_request_errors_total counter")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "rpc_request_errors_total{endpoint=%q} %d\n", endpoint, m.stats[endpoint].errors)
+	}
+
+	fmt.Fprintln(w, "# HELP rpc_request_duration_seconds RPC request latency per endpoint.")
+	fmt.Fprintln(w, "# TYPE rpc_request_duration_seconds histogram")
+	for _, endpoint := range endpoints {
+		stats := m.stats[endpoint]
+		// bucketCounts is already cumulative: Observe increments every
+		// bucket whose bound is at or above the sample, matching
+		// Prometheus's "le" semantics directly.
+		for i, bound := range latencyBucketBounds {
+			fmt.Fprintf(w, "rpc_request_duration_seconds_bucket{endpoint=%q,le=%q} %d\n", endpoint, fmt.Sprintf("%g", bound), stats.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "rpc_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", endpoint, stats.latencyCount)
+		fmt.Fprintf(w, "rpc_request_duration_seconds_sum{endpoint=%q} %g\n", endpoint, stats.latencySum)
+		fmt.Fprintf(w, "rpc_request_duration_seconds_count{endpoint=%q} %d\n", endpoint, stats.latencyCount)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// clientSendBuffer bounds how many pending transactions a slow WebSocket
+// client can accumulate before we start dropping the oldest ones.
+const clientSendBuffer = 32
+
+// pollInterval is how often we check for a new block when the upstream
+// RPC endpoint does not support eth_subscribe over WebSocket.
+const pollInterval = 4 * time.Second
+
+// upstreamRetryInterval bounds how long the notifier stays on the
+// polling fallback before it tries to re-establish the upstream
+// eth_subscribe("newHeads") connection again.
+const upstreamRetryInterval = 30 * time.Second
+
+var wsUpgrader = websocket.Upgrader{
+	// Accept connections regardless of Origin; this server is meant to be
+	// consumed by arbitrary clients, not just same-origin browser pages.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsClient is a single subscriber connection, listening for transactions
+// touching one address.
+type wsClient struct {
+	address string
+	conn    *websocket.Conn
+	send    chan Transaction
+}
+
+// Notifier pushes newly seen transactions to WebSocket clients that
+// subscribed to a matching address. It watches the chain for new blocks
+// either via an upstream eth_subscribe("newHeads") WebSocket connection,
+// or by falling back to polling eth_blockNumber when that isn't
+// available.
+type Notifier struct {
+	client *Client
+	wsURL  string
+
+	mu       sync.Mutex
+	clients  map[string][]*wsClient
+	lastSeen uint64 // highest block number already handled, by either transport
+}
+
+// NewNotifier builds a Notifier that watches new blocks through client
+// and dials wsURL for push notifications from the upstream node.
+func NewNotifier(client *Client, wsURL string) *Notifier {
+	return &Notifier{
+		client:  client,
+		wsURL:   wsURL,
+		clients: make(map[string][]*wsClient),
+	}
+}
+
+// Run watches for new blocks until ctx is cancelled, scanning each one
+// for transactions that match a registered client's address. It prefers
+// the upstream eth_subscribe connection, falling back to polling when
+// that fails or drops, and periodically retries the upstream connection
+// rather than staying on the fallback forever.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		if err := n.runUpstream(ctx); err != nil {
+			log.Printf("notifier: upstream subscription failed, falling back to polling: %v", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		n.runPolling(ctx, upstreamRetryInterval)
+		if ctx.Err() != nil {
+			return
+		}
+		// runPolling already covered us for upstreamRetryInterval, so
+		// loop straight back into trying the upstream subscription.
+	}
+}
+
+// runUpstream maintains a persistent eth_subscribe("newHeads") connection
+// to wsURL via the typed client, dispatching each notification to
+// handleNewBlock. It returns once the connection is lost or ctx is
+// cancelled.
+func (n *Notifier) runUpstream(ctx context.Context) error {
+	if n.wsURL == "" {
+		return fmt.Errorf("no upstream websocket url configured")
+	}
+
+	sub, err := n.client.SubscribeNewHead(ctx, n.wsURL)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	for header := range sub.Headers {
+		n.handleNewBlock(ctx, header.Number)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return sub.Err()
+}
+
+// runPolling scans for new blocks by repeatedly calling
+// eth_blockNumber, used when an upstream WebSocket isn't available. It
+// returns once timeout elapses (as well as on ctx cancellation) so Run
+// can periodically retry the upstream subscription instead of polling
+// forever.
+func (n *Notifier) runPolling(ctx context.Context, timeout time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+			blockNumber, err := n.client.BlockNumber(ctx)
+			if err != nil {
+				log.Printf("notifier: poll eth_blockNumber: %v", err)
+				continue
+			}
+			n.handleNewBlock(ctx, new(big.Int).SetUint64(blockNumber))
+		}
+	}
+}
+
+// handleNewBlock fetches the block identified by number and pushes any
+// transactions touching a subscribed address to that address's clients.
+// number is tracked against the last block handled by either transport,
+// so a block already seen via one doesn't get re-fetched and
+// re-dispatched after Run falls back to (or resumes) the other.
+func (n *Notifier) handleNewBlock(ctx context.Context, number *big.Int) {
+	num := number.Uint64()
+	n.mu.Lock()
+	if num <= n.lastSeen {
+		n.mu.Unlock()
+		return
+	}
+	n.lastSeen = num
+	n.mu.Unlock()
+
+	block, err := n.client.BlockByNumber(ctx, number)
+	if err != nil {
+		log.Printf("notifier: fetch block %s: %v", number, err)
+		return
+	}
+
+	for _, tx := range block.Transactions {
+		transaction := toTransaction(tx)
+		n.dispatch(normalizeAddress(tx.From), transaction)
+		n.dispatch(normalizeAddress(tx.To), transaction)
+	}
+}
+
+// dispatch delivers tx to every client subscribed to address, dropping
+// the oldest queued transaction for a client whose send buffer is full
+// rather than blocking on a slow reader.
+func (n *Notifier) dispatch(address string, tx Transaction) {
+	if address == "" {
+		return
+	}
+	n.mu.Lock()
+	clients := n.clients[address]
+	n.mu.Unlock()
+
+	for _, c := range clients {
+		select {
+		case c.send <- tx:
+		default:
+			select {
+			case <-c.send:
+			default:
+			}
+			select {
+			case c.send <- tx:
+			default:
+			}
+		}
+	}
+}
+
+// HandleWS upgrades the request to a WebSocket connection and streams
+// transactions for the address given in the "address" query parameter
+// until the client disconnects.
+func (n *Notifier) HandleWS(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address not provided", http.StatusBadRequest)
+		return
+	}
+	if err := isValidEthereumAddress(address); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	address = normalizeAddress(address)
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("notifier: upgrade failed: %v", err)
+		return
+	}
+
+	client := &wsClient{
+		address: address,
+		conn:    conn,
+		send:    make(chan Transaction, clientSendBuffer),
+	}
+	n.addClient(client)
+	defer n.removeClient(client)
+
+	// Reading is only done to detect the client going away (browsers and
+	// most WS clients don't send us anything on this connection).
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	for tx := range client.send {
+		if err := conn.WriteJSON(tx); err != nil {
+			return
+		}
+	}
+}
+
+func (n *Notifier) addClient(c *wsClient) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.clients[c.address] = append(n.clients[c.address], c)
+}
+
+// removeClient unregisters c so dispatch stops handing it new
+// transactions. It does not close c.send: a concurrent dispatch may have
+// already read the client slice and be about to send on it, and closing
+// the channel out from under that send would panic the whole notifier.
+// The writer goroutine in HandleWS owns send's lifecycle and exits on
+// its own once conn is closed, leaving the channel to be garbage
+// collected.
+func (n *Notifier) removeClient(c *wsClient) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	peers := n.clients[c.address]
+	for i, peer := range peers {
+		if peer == c {
+			n.clients[c.address] = append(peers[:i], peers[i+1:]...)
+			break
+		}
+	}
+	c.conn.Close()
+}
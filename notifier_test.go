@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+// TestDispatchDropsOldestWhenClientIsSlow verifies that dispatch keeps a
+// slow client's send buffer bounded by dropping the oldest queued
+// transaction, rather than blocking the caller or growing without
+// limit.
+func TestDispatchDropsOldestWhenClientIsSlow(t *testing.T) {
+	n := NewNotifier(nil, "")
+	c := &wsClient{address: "0xabc", send: make(chan Transaction, clientSendBuffer)}
+	n.addClient(c)
+
+	const sent = clientSendBuffer + 5
+	for i := 0; i < sent; i++ {
+		n.dispatch(c.address, Transaction{Hash: hashFor(i)})
+	}
+
+	var got []string
+	close(c.send)
+	for tx := range c.send {
+		got = append(got, tx.Hash)
+	}
+
+	if len(got) != clientSendBuffer {
+		t.Fatalf("got %d queued transactions, want %d", len(got), clientSendBuffer)
+	}
+	for i, hash := range got {
+		want := hashFor(sent - clientSendBuffer + i)
+		if hash != want {
+			t.Errorf("queued[%d] = %q, want %q (oldest should have been dropped)", i, hash, want)
+		}
+	}
+}
+
+// TestDispatchIgnoresUnsubscribedAddress verifies that dispatch is a
+// no-op when no client is registered for address, rather than panicking
+// on a missing map entry.
+func TestDispatchIgnoresUnsubscribedAddress(t *testing.T) {
+	n := NewNotifier(nil, "")
+	n.dispatch("0xnobodyhome", Transaction{Hash: "0x1"})
+}
+
+func hashFor(i int) string {
+	const hex = "0123456789abcdef"
+	return "0x" + string(hex[i%16]) + string(hex[(i/16)%16])
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token-bucket rate limiter used to keep each
+// RPC endpoint under whatever quota it enforces (public nodes like
+// ethereum-rpc.publicnode.com cap requests per second).
+type tokenBucket struct {
+	mu         sync.Mutex
+	capacity   float64
+	tokens     float64
+	refillRate float64 // tokens per second
+	lastRefill time.Time
+}
+
+// newTokenBucket returns a bucket that holds at most capacity tokens and
+// refills at refillPerSecond tokens/second, starting full.
+func newTokenBucket(capacity, refillPerSecond float64) *tokenBucket {
+	return &tokenBucket{
+		capacity:   capacity,
+		tokens:     capacity,
+		refillRate: refillPerSecond,
+		lastRefill: time.Now(),
+	}
+}
+
+// Allow reports whether a request may proceed right now, consuming one
+// token if so.
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+	b.tokens += elapsed * b.refillRate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
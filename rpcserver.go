@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// passthroughNamespaces are forwarded verbatim to the upstream node
+// rather than dispatched to a registered Go service.
+var passthroughNamespaces = map[string]bool{
+	"eth":  true,
+	"net":  true,
+	"web3": true,
+}
+
+// RPCServer is a namespaced JSON-RPC 2.0 dispatcher, in the spirit of
+// ethermint's rpc/namespaces/* split: a method name like
+// "parser_getTransactions" is split into a namespace ("parser") and a
+// namespace-local method ("getTransactions"), and calls are dispatched
+// via reflection to a Go service struct registered for that namespace.
+// The "eth"/"net"/"web3" namespaces are handled specially: their calls
+// are proxied straight through to the upstream node instead of being
+// dispatched locally. Both HTTP POST and WebSocket transports share the
+// same dispatcher, and both accept either a single request or a batch
+// (a JSON array of requests).
+type RPCServer struct {
+	upstream *Client
+	services map[string]reflect.Value
+}
+
+// NewRPCServer returns an RPCServer that proxies eth_/net_/web3_ calls
+// to upstream.
+func NewRPCServer(upstream *Client) *RPCServer {
+	return &RPCServer{
+		upstream: upstream,
+		services: make(map[string]reflect.Value),
+	}
+}
+
+// RegisterService makes service's exported methods callable under
+// "<namespace>_<method>", with the method's first letter lowercased in
+// the JSON-RPC method name (e.g. GetTransactions -> getTransactions).
+func (s *RPCServer) RegisterService(namespace string, service interface{}) {
+	s.services[namespace] = reflect.ValueOf(service)
+}
+
+type rpcRequestEnvelope struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      interface{}     `json:"id"`
+}
+
+type rpcResponseEnvelope struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *RPCError   `json:"error,omitempty"`
+	ID      interface{} `json:"id"`
+}
+
+// handle dispatches a single JSON-RPC request and builds its response
+// envelope.
+func (s *RPCServer) handle(ctx context.Context, req rpcRequestEnvelope) rpcResponseEnvelope {
+	result, rpcErr := s.dispatch(ctx, req.Method, req.Params)
+	return rpcResponseEnvelope{JSONRPC: "2.0", Result: result, Error: rpcErr, ID: req.ID}
+}
+
+// dispatch resolves method's namespace and either proxies it upstream
+// or invokes the matching method on a registered namespace service.
+func (s *RPCServer) dispatch(ctx context.Context, method string, rawParams json.RawMessage) (interface{}, *RPCError) {
+	underscore := strings.IndexByte(method, '_')
+	if underscore < 0 {
+		return nil, &RPCError{Code: -32601, Message: fmt.Sprintf("method not found: %s", method)}
+	}
+	namespace, subMethod := method[:underscore], method[underscore+1:]
+
+	if passthroughNamespaces[namespace] {
+		return s.proxyUpstream(ctx, method, rawParams)
+	}
+
+	svc, ok := s.services[namespace]
+	if !ok {
+		return nil, &RPCError{Code: -32601, Message: fmt.Sprintf("unknown namespace: %s", namespace)}
+	}
+	return s.dispatchNative(ctx, svc, subMethod, rawParams)
+}
+
+// proxyUpstream forwards method and its params to the upstream node
+// as-is and returns its raw result.
+func (s *RPCServer) proxyUpstream(ctx context.Context, method string, rawParams json.RawMessage) (interface{}, *RPCError) {
+	var params []interface{}
+	if len(rawParams) > 0 {
+		if err := json.Unmarshal(rawParams, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)}
+		}
+	}
+
+	var result json.RawMessage
+	if err := s.upstream.call(ctx, &result, method, params...); err != nil {
+		if rpcErr, ok := err.(*RPCError); ok {
+			return nil, rpcErr
+		}
+		return nil, &RPCError{Code: -32000, Message: err.Error()}
+	}
+	return result, nil
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// dispatchNative invokes subMethod (lowerCamelCase, e.g.
+// "getTransactions") on svc (its Go method is the same name with an
+// uppercase first letter) via reflection, filling in ctx for any
+// context.Context parameter and decoding the rest positionally from
+// rawParams.
+func (s *RPCServer) dispatchNative(ctx context.Context, svc reflect.Value, subMethod string, rawParams json.RawMessage) (interface{}, *RPCError) {
+	if subMethod == "" {
+		return nil, &RPCError{Code: -32601, Message: "method not found"}
+	}
+	methodName := strings.ToUpper(subMethod[:1]) + subMethod[1:]
+	method := svc.MethodByName(methodName)
+	if !method.IsValid() {
+		return nil, &RPCError{Code: -32601, Message: fmt.Sprintf("method not found: %s", subMethod)}
+	}
+
+	var params []json.RawMessage
+	if len(rawParams) > 0 {
+		if err := json.Unmarshal(rawParams, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)}
+		}
+	}
+
+	methodType := method.Type()
+	args := make([]reflect.Value, methodType.NumIn())
+	nextParam := 0
+	for i := 0; i < methodType.NumIn(); i++ {
+		argType := methodType.In(i)
+		if argType == contextType {
+			args[i] = reflect.ValueOf(ctx)
+			continue
+		}
+		if nextParam >= len(params) {
+			return nil, &RPCError{Code: -32602, Message: "invalid params: too few arguments"}
+		}
+		argPtr := reflect.New(argType)
+		if err := json.Unmarshal(params[nextParam], argPtr.Interface()); err != nil {
+			return nil, &RPCError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)}
+		}
+		args[i] = argPtr.Elem()
+		nextParam++
+	}
+
+	results := method.Call(args)
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	last := results[len(results)-1]
+	if last.Type() == errorType {
+		if !last.IsNil() {
+			return nil, &RPCError{Code: -32000, Message: last.Interface().(error).Error()}
+		}
+		if len(results) == 1 {
+			return nil, nil
+		}
+	}
+	return results[0].Interface(), nil
+}
+
+// ServeHTTP implements the HTTP POST transport. The body is either a
+// single JSON-RPC request object or a batch (a JSON array of them).
+func (s *RPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := readAndPeekArray(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if body.isBatch {
+		var reqs []rpcRequestEnvelope
+		if err := json.Unmarshal(body.raw, &reqs); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid batch request: %v", err), http.StatusBadRequest)
+			return
+		}
+		responses := make([]rpcResponseEnvelope, len(reqs))
+		for i, req := range reqs {
+			responses[i] = s.handle(r.Context(), req)
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+
+	var req rpcRequestEnvelope
+	if err := json.Unmarshal(body.raw, &req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(s.handle(r.Context(), req))
+}
+
+// HandleWS implements the WebSocket transport: the same dispatcher as
+// ServeHTTP, but reading requests from and writing responses to a
+// persistent WebSocket connection instead of one request body.
+func (s *RPCServer) HandleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("rpcserver: upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if isJSONArray(raw) {
+			var reqs []rpcRequestEnvelope
+			if err := json.Unmarshal(raw, &reqs); err != nil {
+				continue
+			}
+			responses := make([]rpcResponseEnvelope, len(reqs))
+			for i, req := range reqs {
+				responses[i] = s.handle(r.Context(), req)
+			}
+			if err := conn.WriteJSON(responses); err != nil {
+				return
+			}
+			continue
+		}
+
+		var req rpcRequestEnvelope
+		if err := json.Unmarshal(raw, &req); err != nil {
+			continue
+		}
+		if err := conn.WriteJSON(s.handle(r.Context(), req)); err != nil {
+			return
+		}
+	}
+}
+
+type requestBody struct {
+	raw     []byte
+	isBatch bool
+}
+
+// readAndPeekArray reads the request body and reports whether it's a
+// JSON array (a batch) or a single object.
+func readAndPeekArray(r *http.Request) (requestBody, error) {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return requestBody{}, err
+	}
+	return requestBody{raw: raw, isBatch: isJSONArray(raw)}, nil
+}
+
+// isJSONArray reports whether raw's first non-whitespace byte is '['.
+func isJSONArray(raw []byte) bool {
+	for _, b := range raw {
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		default:
+			return b == '['
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"time"
+)
+
+// scanPollInterval is how often the scanner checks eth_blockNumber for
+// new blocks to index.
+const scanPollInterval = 4 * time.Second
+
+// reorgCheckDepth is how far back the scanner is willing to walk to find
+// a common ancestor when a reorg is detected.
+const reorgCheckDepth = 64
+
+// Scanner walks the chain forward from a persisted cursor, indexing
+// every transaction it sees for a subscribed address into storage so
+// GetTransactions can answer with the full history since the cursor
+// started, not just the latest block. Addresses nobody has subscribed to
+// are skipped, so the index doesn't grow across the whole chain. It
+// detects reorgs by comparing each new block's parent hash against the
+// hash it recorded for the previous block number, and rewinds the
+// cursor to the common ancestor when they disagree.
+type Scanner struct {
+	client  *Client
+	storage Storage
+	subs    *Subscriptions
+}
+
+// NewScanner returns a Scanner that indexes blocks fetched through
+// client into storage, restricted to the addresses registered in subs.
+func NewScanner(client *Client, storage Storage, subs *Subscriptions) *Scanner {
+	return &Scanner{client: client, storage: storage, subs: subs}
+}
+
+// Run backfills from the persisted cursor and then polls for new blocks
+// until ctx is cancelled.
+func (s *Scanner) Run(ctx context.Context) {
+	if err := s.catchUp(ctx); err != nil {
+		log.Printf("scanner: initial catch-up: %v", err)
+	}
+
+	ticker := time.NewTicker(scanPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.catchUp(ctx); err != nil {
+				log.Printf("scanner: catch-up: %v", err)
+			}
+		}
+	}
+}
+
+// catchUp indexes every block between the persisted cursor and the
+// current chain head.
+func (s *Scanner) catchUp(ctx context.Context) error {
+	latest, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	next, ok, err := s.storage.LastProcessedBlock()
+	if err != nil {
+		return err
+	}
+	if ok {
+		next++
+	} else {
+		// Nothing indexed yet: start from the current head rather than
+		// genesis, since a fresh service has no subscribers with history
+		// to backfill for yet.
+		next = latest
+	}
+
+	for n := next; n <= latest; n++ {
+		reorged, err := s.processBlock(ctx, n)
+		if err != nil {
+			return err
+		}
+		if reorged {
+			// The cursor was just rewound to the common ancestor; restart
+			// the walk from there instead of continuing at n+1, which
+			// would leave the diverged range unindexed and the cursor
+			// back above it, silently undoing the rewind.
+			return s.catchUp(ctx)
+		}
+	}
+	return nil
+}
+
+// processBlock indexes a single block, detecting a reorg first by
+// comparing its parent hash against the previously recorded hash for
+// block n-1. It reports reorged true when it rewound storage instead of
+// indexing n, so the caller knows to restart its walk rather than
+// advance past the diverged range.
+func (s *Scanner) processBlock(ctx context.Context, n uint64) (reorged bool, err error) {
+	block, err := s.client.BlockByNumber(ctx, new(big.Int).SetUint64(n))
+	if err != nil {
+		return false, err
+	}
+
+	if n > 0 {
+		expectedParent, ok, err := s.storage.BlockHash(n - 1)
+		if err != nil {
+			return false, err
+		}
+		if ok && expectedParent != block.ParentHash {
+			log.Printf("scanner: reorg detected at block %d, rewinding", n)
+			if err := s.rewindToCommonAncestor(ctx, n-1); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+
+	for _, tx := range block.Transactions {
+		indexed := IndexedTransaction{Transaction: toTransaction(tx), BlockNumber: n}
+		from := normalizeAddress(tx.From)
+		if s.subs.Contains(from) {
+			if err := s.storage.AddTransaction(from, indexed); err != nil {
+				return false, err
+			}
+		}
+		to := normalizeAddress(tx.To)
+		if to != "" && to != from && s.subs.Contains(to) {
+			if err := s.storage.AddTransaction(to, indexed); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if err := s.storage.SetBlockHash(n, block.Hash); err != nil {
+		return false, err
+	}
+	return false, s.storage.SetLastProcessedBlock(n)
+}
+
+// rewindToCommonAncestor walks backward from atBlock, re-fetching blocks
+// from the chain and comparing them against recorded hashes, up to
+// reorgCheckDepth blocks. It rewinds storage to the first block number
+// where the hashes agree (or, failing that, to the bottom of the search
+// window), so the next catch-up re-processes the diverged range.
+func (s *Scanner) rewindToCommonAncestor(ctx context.Context, atBlock uint64) error {
+	floor := uint64(0)
+	if atBlock > reorgCheckDepth {
+		floor = atBlock - reorgCheckDepth
+	}
+
+	for n := atBlock; n > floor; n-- {
+		chainBlock, err := s.client.BlockByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return err
+		}
+		stored, ok, err := s.storage.BlockHash(n)
+		if err != nil {
+			return err
+		}
+		if !ok || stored == chainBlock.Hash {
+			return s.storage.RewindTo(n)
+		}
+	}
+	return s.storage.RewindTo(floor)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeChainTx is the fixture shape for a transaction embedded in a
+// fakeChainBlock.
+type fakeChainTx struct {
+	from, to, hash string
+}
+
+// fakeChainBlock is the fixture shape for one block of a fakeChain.
+type fakeChainBlock struct {
+	hash, parentHash string
+	txs              []fakeChainTx
+}
+
+// fakeChain serves eth_blockNumber/eth_getBlockByNumber over HTTP from
+// an in-memory set of blocks that a test can swap out mid-run, so it
+// can simulate a reorg between two calls to Scanner.catchUp.
+type fakeChain struct {
+	mu     sync.Mutex
+	blocks map[uint64]fakeChainBlock
+	latest uint64
+}
+
+func newFakeChainServer(t *testing.T) (*httptest.Server, *fakeChain) {
+	t.Helper()
+	chain := &fakeChain{blocks: make(map[uint64]fakeChainBlock)}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+
+		chain.mu.Lock()
+		defer chain.mu.Unlock()
+
+		switch req.Method {
+		case "eth_blockNumber":
+			data, _ := json.Marshal(hexString(chain.latest))
+			json.NewEncoder(w).Encode(rpcResponse{ID: req.ID, Result: data})
+		case "eth_getBlockByNumber":
+			tag, _ := req.Params[0].(string)
+			n, err := hexToUint64(tag)
+			if err != nil {
+				t.Fatalf("decode block tag %q: %v", tag, err)
+			}
+			block, ok := chain.blocks[n]
+			if !ok {
+				json.NewEncoder(w).Encode(rpcResponse{ID: req.ID, Result: []byte("null")})
+				return
+			}
+			raw := map[string]interface{}{
+				"number":       hexString(n),
+				"hash":         block.hash,
+				"parentHash":   block.parentHash,
+				"timestamp":    "0x0",
+				"transactions": rawTxs(n, block.txs),
+			}
+			data, _ := json.Marshal(raw)
+			json.NewEncoder(w).Encode(rpcResponse{ID: req.ID, Result: data})
+		default:
+			t.Fatalf("unexpected method %q", req.Method)
+		}
+	}))
+	return srv, chain
+}
+
+func hexString(n uint64) string {
+	return "0x" + bigHex(n)
+}
+
+func bigHex(n uint64) string {
+	const digits = "0123456789abcdef"
+	if n == 0 {
+		return "0"
+	}
+	var buf []byte
+	for n > 0 {
+		buf = append([]byte{digits[n%16]}, buf...)
+		n /= 16
+	}
+	return string(buf)
+}
+
+func rawTxs(blockNumber uint64, txs []fakeChainTx) []interface{} {
+	out := make([]interface{}, len(txs))
+	for i, tx := range txs {
+		out[i] = map[string]interface{}{
+			"from":        tx.from,
+			"to":          tx.to,
+			"hash":        tx.hash,
+			"value":       "0x0",
+			"nonce":       "0x0",
+			"blockNumber": hexString(blockNumber),
+		}
+	}
+	return out
+}
+
+// TestScannerReorgRewindsAndReprocesses verifies that when the chain the
+// scanner is following reorgs, catchUp rewinds storage to the common
+// ancestor and reprocesses the diverged range on the new chain, instead
+// of leaving the index holding a mix of old and new blocks or silently
+// undoing the rewind by racing ahead of it.
+func TestScannerReorgRewindsAndReprocesses(t *testing.T) {
+	srv, chain := newFakeChainServer(t)
+	defer srv.Close()
+
+	const addr = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	client := NewClient(srv.URL)
+	storage := NewMemoryStorage()
+	subs := NewSubscriptions()
+	subs.Add(addr)
+	scanner := NewScanner(client, storage, subs)
+
+	// Original chain: blocks 0-3, with an indexed transaction to addr in
+	// block 3.
+	chain.mu.Lock()
+	chain.blocks[0] = fakeChainBlock{hash: "0xh0", parentHash: "0x0"}
+	chain.blocks[1] = fakeChainBlock{hash: "0xh1", parentHash: "0xh0"}
+	chain.blocks[2] = fakeChainBlock{hash: "0xh2", parentHash: "0xh1"}
+	chain.blocks[3] = fakeChainBlock{hash: "0xh3", parentHash: "0xh2", txs: []fakeChainTx{
+		{from: "0xsender", to: addr, hash: "0xtx-old-3"},
+	}}
+	chain.latest = 3
+	chain.mu.Unlock()
+
+	if err := scanner.catchUp(context.Background()); err != nil {
+		t.Fatalf("initial catchUp: %v", err)
+	}
+
+	last, ok, err := storage.LastProcessedBlock()
+	if err != nil || !ok || last != 3 {
+		t.Fatalf("after initial catchUp: last=%d ok=%v err=%v, want 3/true", last, ok, err)
+	}
+
+	// Reorg: block 3 is replaced by a sibling with the same parent, and a
+	// new block 4 extends the new chain. The old block 3's transaction
+	// must be purged from the index and replaced by the new chain's.
+	chain.mu.Lock()
+	chain.blocks[3] = fakeChainBlock{hash: "0xh3b", parentHash: "0xh2", txs: []fakeChainTx{
+		{from: "0xsender", to: addr, hash: "0xtx-new-3"},
+	}}
+	chain.blocks[4] = fakeChainBlock{hash: "0xh4b", parentHash: "0xh3b", txs: []fakeChainTx{
+		{from: addr, to: "0xrecipient", hash: "0xtx-new-4"},
+	}}
+	chain.latest = 4
+	chain.mu.Unlock()
+
+	if err := scanner.catchUp(context.Background()); err != nil {
+		t.Fatalf("post-reorg catchUp: %v", err)
+	}
+
+	last, ok, err = storage.LastProcessedBlock()
+	if err != nil || !ok || last != 4 {
+		t.Fatalf("after post-reorg catchUp: last=%d ok=%v err=%v, want 4/true", last, ok, err)
+	}
+
+	txs, err := storage.TransactionsByAddress(addr)
+	if err != nil {
+		t.Fatalf("TransactionsByAddress: %v", err)
+	}
+
+	var hashes []string
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash)
+	}
+	want := []string{"0xtx-new-3", "0xtx-new-4"}
+	if len(hashes) != len(want) {
+		t.Fatalf("indexed hashes = %v, want %v", hashes, want)
+	}
+	for i, h := range want {
+		if hashes[i] != h {
+			t.Errorf("indexed hashes = %v, want %v (old chain's block 3 tx should have been rewound away)", hashes, want)
+		}
+	}
+}
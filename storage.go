@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// IndexedTransaction is a Transaction plus the block it was seen in, as
+// stored by the Scanner's per-address index.
+type IndexedTransaction struct {
+	Transaction
+	BlockNumber uint64 `json:"blockNumber"`
+}
+
+// Storage persists the scanner's cursor, its recent-block-hash window
+// (used for reorg detection), and the per-address transaction index. It
+// is implemented by MemoryStorage and FileStorage so the scanner can run
+// either ephemeral or durable across restarts.
+type Storage interface {
+	// LastProcessedBlock returns the last block number the scanner
+	// finished indexing. ok is false if nothing has been processed yet.
+	LastProcessedBlock() (number uint64, ok bool, err error)
+	SetLastProcessedBlock(number uint64) error
+
+	// BlockHash returns the hash recorded for a given block number, used
+	// to detect a reorg when a new block's parent hash no longer matches.
+	BlockHash(number uint64) (hash string, ok bool, err error)
+	SetBlockHash(number uint64, hash string) error
+
+	// RewindTo discards recorded block hashes and index entries for
+	// blocks after number, and resets the cursor to number.
+	RewindTo(number uint64) error
+
+	AddTransaction(address string, tx IndexedTransaction) error
+	TransactionsByAddress(address string) ([]IndexedTransaction, error)
+}
+
+// memoryState is the data shared by MemoryStorage and FileStorage; the
+// latter simply persists a memoryState to disk after every write.
+type memoryState struct {
+	LastBlock   uint64                          `json:"lastBlock"`
+	HasLastBlk  bool                            `json:"hasLastBlock"`
+	BlockHashes map[uint64]string               `json:"blockHashes"`
+	ByAddress   map[string][]IndexedTransaction `json:"byAddress"`
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		BlockHashes: make(map[uint64]string),
+		ByAddress:   make(map[string][]IndexedTransaction),
+	}
+}
+
+// MemoryStorage is an in-memory Storage backend. State is lost on
+// restart; useful for tests and for running the scanner without a data
+// directory.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	state *memoryState
+}
+
+// NewMemoryStorage returns an empty in-memory Storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{state: newMemoryState()}
+}
+
+func (s *MemoryStorage) LastProcessedBlock() (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.LastBlock, s.state.HasLastBlk, nil
+}
+
+func (s *MemoryStorage) SetLastProcessedBlock(number uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.LastBlock = number
+	s.state.HasLastBlk = true
+	return nil
+}
+
+func (s *MemoryStorage) BlockHash(number uint64) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, ok := s.state.BlockHashes[number]
+	return hash, ok, nil
+}
+
+func (s *MemoryStorage) SetBlockHash(number uint64, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.BlockHashes[number] = hash
+	return nil
+}
+
+func (s *MemoryStorage) RewindTo(number uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n := range s.state.BlockHashes {
+		if n > number {
+			delete(s.state.BlockHashes, n)
+		}
+	}
+	for addr, txs := range s.state.ByAddress {
+		kept := txs[:0]
+		for _, tx := range txs {
+			if tx.BlockNumber <= number {
+				kept = append(kept, tx)
+			}
+		}
+		s.state.ByAddress[addr] = kept
+	}
+	s.state.LastBlock = number
+	s.state.HasLastBlk = true
+	return nil
+}
+
+func (s *MemoryStorage) AddTransaction(address string, tx IndexedTransaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.ByAddress[address] = append(s.state.ByAddress[address], tx)
+	return nil
+}
+
+func (s *MemoryStorage) TransactionsByAddress(address string) ([]IndexedTransaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]IndexedTransaction(nil), s.state.ByAddress[address]...), nil
+}
+
+// FileStorage is a Storage backend that keeps the same state as
+// MemoryStorage in memory and persists it as JSON to a file, so the
+// scanner can resume from its cursor after a restart instead of
+// rescanning the chain from genesis. AddTransaction and SetBlockHash
+// only update memory; the scanner calls SetLastProcessedBlock exactly
+// once per block after indexing its transactions, so persisting there
+// batches the write to once per block instead of once per call.
+type FileStorage struct {
+	mem  *MemoryStorage
+	path string
+}
+
+// NewFileStorage returns a Storage backed by path, loading any existing
+// state from it first.
+func NewFileStorage(path string) (*FileStorage, error) {
+	fs := &FileStorage{mem: NewMemoryStorage(), path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fs, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, fs.mem.state); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return fs, nil
+}
+
+func (fs *FileStorage) persist() error {
+	fs.mem.mu.Lock()
+	data, err := json.Marshal(fs.mem.state)
+	fs.mem.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	if err := os.WriteFile(fs.path, data, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", fs.path, err)
+	}
+	return nil
+}
+
+func (fs *FileStorage) LastProcessedBlock() (uint64, bool, error) {
+	return fs.mem.LastProcessedBlock()
+}
+
+func (fs *FileStorage) SetLastProcessedBlock(number uint64) error {
+	if err := fs.mem.SetLastProcessedBlock(number); err != nil {
+		return err
+	}
+	return fs.persist()
+}
+
+func (fs *FileStorage) BlockHash(number uint64) (string, bool, error) {
+	return fs.mem.BlockHash(number)
+}
+
+func (fs *FileStorage) SetBlockHash(number uint64, hash string) error {
+	return fs.mem.SetBlockHash(number, hash)
+}
+
+func (fs *FileStorage) RewindTo(number uint64) error {
+	if err := fs.mem.RewindTo(number); err != nil {
+		return err
+	}
+	return fs.persist()
+}
+
+func (fs *FileStorage) AddTransaction(address string, tx IndexedTransaction) error {
+	return fs.mem.AddTransaction(address, tx)
+}
+
+func (fs *FileStorage) TransactionsByAddress(address string) ([]IndexedTransaction, error) {
+	return fs.mem.TransactionsByAddress(address)
+}
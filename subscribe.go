@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// HeadSubscription is a live eth_subscribe("newHeads") stream. Headers
+// arrive on the Headers channel; Err reports why the subscription ended
+// once Headers is closed.
+type HeadSubscription struct {
+	Headers chan *EthHeader
+	conn    *websocket.Conn
+	err     error
+}
+
+// Err returns the error that terminated the subscription, if any. It is
+// only meaningful after Headers has been closed.
+func (s *HeadSubscription) Err() error {
+	return s.err
+}
+
+// Unsubscribe closes the underlying WebSocket connection, ending the
+// subscription.
+func (s *HeadSubscription) Unsubscribe() {
+	s.conn.Close()
+}
+
+// SubscribeNewHead opens a WebSocket connection to wsURL and subscribes
+// to new block headers via eth_subscribe("newHeads"). The returned
+// subscription's Headers channel is closed when the connection drops or
+// ctx is cancelled.
+func (c *Client) SubscribeNewHead(ctx context.Context, wsURL string) (*HeadSubscription, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial upstream: %w", err)
+	}
+
+	sub := rpcRequest{JSONRPC: "2.0", Method: "eth_subscribe", Params: []interface{}{"newHeads"}, ID: 1}
+	if err := conn.WriteJSON(sub); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+
+	s := &HeadSubscription{
+		Headers: make(chan *EthHeader),
+		conn:    conn,
+	}
+
+	go func() {
+		defer close(s.Headers)
+		defer conn.Close()
+
+		go func() {
+			<-ctx.Done()
+			conn.Close()
+		}()
+
+		for {
+			var msg struct {
+				Params struct {
+					Result map[string]interface{} `json:"result"`
+				} `json:"params"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				if ctx.Err() == nil {
+					s.err = fmt.Errorf("read: %w", err)
+				}
+				return
+			}
+			if msg.Params.Result == nil {
+				continue // subscription confirmation, not a head event
+			}
+			header, err := decodeHeader(msg.Params.Result)
+			if err != nil {
+				s.err = fmt.Errorf("decode header: %w", err)
+				return
+			}
+			select {
+			case s.Headers <- &header:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return s, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// normalizeAddress lowercases an Ethereum address so an EIP-55
+// checksummed (mixed-case) address and its lowercase form key the same
+// map entry and storage bucket. The node itself returns addresses
+// lowercase, so every address used as a map key or index key is
+// normalized through this first.
+func normalizeAddress(address string) string {
+	return strings.ToLower(address)
+}
+
+// Subscriptions tracks which addresses have been registered through
+// /subscribe. It's shared between ParserService, which answers
+// /subscribe and rejects duplicates, and Scanner, which consults it to
+// decide which addresses are worth indexing, so the transaction index
+// stays bounded to addresses someone actually asked about.
+type Subscriptions struct {
+	mu   sync.Mutex
+	addr map[string]bool
+}
+
+// NewSubscriptions returns an empty set of subscriptions.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{addr: make(map[string]bool)}
+}
+
+// Add registers address as subscribed, returning false if it was already
+// subscribed. address must already be normalized.
+func (s *Subscriptions) Add(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.addr[address] {
+		return false
+	}
+	s.addr[address] = true
+	return true
+}
+
+// Contains reports whether address is subscribed. address must already
+// be normalized.
+func (s *Subscriptions) Contains(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr[address]
+}
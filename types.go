@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// EthHeader is the typed form of the object returned by
+// eth_getBlockByNumber/eth_getBlockByHash (without the transaction list)
+// and by eth_subscribe("newHeads") notifications.
+type EthHeader struct {
+	Number     *big.Int
+	Hash       string
+	ParentHash string
+	Time       uint64
+}
+
+// EthTransaction is the typed form of a transaction object as returned
+// embedded in a block or by eth_getTransactionByHash.
+type EthTransaction struct {
+	Hash     string
+	From     string
+	To       string // empty for contract-creation transactions
+	Value    *big.Int
+	Nonce    uint64
+	BlockNum *big.Int
+}
+
+// EthBlock is the typed form of the object returned by
+// eth_getBlockByNumber/eth_getBlockByHash with full transaction objects.
+type EthBlock struct {
+	EthHeader
+	Transactions []*EthTransaction
+}
+
+// Receipt is the typed form of the object returned by
+// eth_getTransactionReceipt.
+type Receipt struct {
+	TxHash   string
+	BlockNum *big.Int
+	Status   uint64
+	GasUsed  uint64
+}
+
+// RPCError wraps a JSON-RPC error object so callers can distinguish an
+// RPC-level failure (bad request, node rejected the call, ...) from a
+// transport error.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// hexToBigInt decodes a "0x..." quantity into a *big.Int. An empty or
+// nil-ish string decodes to zero, matching how the RPC represents a
+// missing value (e.g. "to" on a contract-creation transaction).
+func hexToBigInt(s string) (*big.Int, error) {
+	if s == "" || s == "0x" {
+		return big.NewInt(0), nil
+	}
+	s = strings.TrimPrefix(s, "0x")
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	return n, nil
+}
+
+// hexToUint64 decodes a "0x..." quantity into a uint64.
+func hexToUint64(s string) (uint64, error) {
+	s = strings.TrimPrefix(s, "0x")
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex quantity %q: %w", s, err)
+	}
+	return n, nil
+}
+
+// hexToBytes decodes a hex string (without a "0x" prefix) into raw
+// bytes.
+func hexToBytes(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex data: %w", err)
+	}
+	return b, nil
+}
+
+// decodeHeader parses the header fields out of a raw eth_getBlockByNumber
+// (or newHeads) result.
+func decodeHeader(raw map[string]interface{}) (EthHeader, error) {
+	numberHex, _ := raw["number"].(string)
+	number, err := hexToBigInt(numberHex)
+	if err != nil {
+		return EthHeader{}, fmt.Errorf("decode number: %w", err)
+	}
+	timeHex, _ := raw["timestamp"].(string)
+	ts, err := hexToUint64(timeHex)
+	if err != nil {
+		return EthHeader{}, fmt.Errorf("decode timestamp: %w", err)
+	}
+	hash, _ := raw["hash"].(string)
+	parentHash, _ := raw["parentHash"].(string)
+	return EthHeader{
+		Number:     number,
+		Hash:       hash,
+		ParentHash: parentHash,
+		Time:       ts,
+	}, nil
+}
+
+// decodeTransaction parses a single transaction object out of a raw
+// eth_getBlockByNumber (with full transactions) result.
+func decodeTransaction(raw map[string]interface{}) (*EthTransaction, error) {
+	from, _ := raw["from"].(string)
+	to, _ := raw["to"].(string)
+	hash, _ := raw["hash"].(string)
+
+	valueHex, _ := raw["value"].(string)
+	value, err := hexToBigInt(valueHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode value: %w", err)
+	}
+
+	nonceHex, _ := raw["nonce"].(string)
+	nonce, err := hexToUint64(nonceHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode nonce: %w", err)
+	}
+
+	blockNumHex, _ := raw["blockNumber"].(string)
+	blockNum, err := hexToBigInt(blockNumHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode blockNumber: %w", err)
+	}
+
+	return &EthTransaction{
+		Hash:     hash,
+		From:     from,
+		To:       to,
+		Value:    value,
+		Nonce:    nonce,
+		BlockNum: blockNum,
+	}, nil
+}
+
+// decodeBlock parses a raw eth_getBlockByNumber/eth_getBlockByHash result
+// (requested with full transaction objects) into an EthBlock.
+func decodeBlock(raw map[string]interface{}) (*EthBlock, error) {
+	header, err := decodeHeader(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rawTxs, _ := raw["transactions"].([]interface{})
+	txs := make([]*EthTransaction, 0, len(rawTxs))
+	for _, rawTx := range rawTxs {
+		txMap, ok := rawTx.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tx, err := decodeTransaction(txMap)
+		if err != nil {
+			return nil, fmt.Errorf("decode transaction: %w", err)
+		}
+		txs = append(txs, tx)
+	}
+
+	return &EthBlock{EthHeader: header, Transactions: txs}, nil
+}